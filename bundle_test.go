@@ -0,0 +1,149 @@
+/*
+ * Copyright 2019 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package perspectivego
+
+import (
+	"archive/zip"
+	"github.com/spf13/afero"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// tamperZipEntry rewrites the zip archive at path, appending a byte to the
+// content of the entry named name so it no longer matches its recorded
+// checksum.
+func tamperZipEntry(path, name string) error {
+	reader, err := zip.OpenReader(path)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	tmp, err := ioutil.TempFile(filepath.Dir(path), "tamper-*.worldball")
+	if err != nil {
+		return err
+	}
+	defer tmp.Close()
+
+	writer := zip.NewWriter(tmp)
+	for _, f := range reader.File {
+		rc, err := f.Open()
+		if err != nil {
+			return err
+		}
+		data, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			return err
+		}
+		if f.Name == name {
+			data = append(data, '!')
+		}
+		entry, err := writer.Create(f.Name)
+		if err != nil {
+			return err
+		}
+		if _, err := entry.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := writer.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+func testBundle(t *testing.T) *Bundle {
+	assets := afero.NewMemMapFs()
+	if err := afero.WriteFile(assets, "block.mesh", []byte("mesh-data"), 0644); err != nil {
+		t.Fatalf("could not seed test asset: %v", err)
+	}
+	return &Bundle{
+		World: &World{},
+		Puzzle: []*Puzzle{
+			{
+				Target: 1,
+				Block: []*Block{
+					{Name: "a", Mesh: "block.mesh", Location: &Location{X: 1, Y: 2, Z: 3}},
+				},
+			},
+		},
+		Assets: assets,
+	}
+}
+
+func TestBundleRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.worldball")
+	want := testBundle(t)
+
+	if err := WriteBundle(path, want); err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+
+	got, err := ReadBundle(path)
+	if err != nil {
+		t.Fatalf("ReadBundle failed: %v", err)
+	}
+
+	if len(got.Puzzle) != len(want.Puzzle) {
+		t.Fatalf("got %d puzzles, want %d", len(got.Puzzle), len(want.Puzzle))
+	}
+	if got.Puzzle[0].Block[0].Mesh != "block.mesh" {
+		t.Fatalf("got mesh %q, want block.mesh", got.Puzzle[0].Block[0].Mesh)
+	}
+	if exists, err := afero.Exists(got.Assets, "block.mesh"); err != nil || !exists {
+		t.Fatalf("round-tripped bundle is missing asset block.mesh: exists=%v err=%v", exists, err)
+	}
+}
+
+func TestReadBundleMissingAsset(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.worldball")
+	bundle := testBundle(t)
+	bundle.Assets = afero.NewMemMapFs() // drop the referenced asset
+
+	if err := WriteBundle(path, bundle); err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+
+	_, err := ReadBundle(path)
+	missing, ok := err.(*MissingAssetError)
+	if !ok {
+		t.Fatalf("got error %v, want *MissingAssetError", err)
+	}
+	if len(missing.Names) != 1 || missing.Names[0] != "block.mesh" {
+		t.Fatalf("got missing assets %v, want [block.mesh]", missing.Names)
+	}
+}
+
+func TestReadBundleChecksumMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.worldball")
+	bundle := testBundle(t)
+	if err := WriteBundle(path, bundle); err != nil {
+		t.Fatalf("WriteBundle failed: %v", err)
+	}
+
+	if err := tamperZipEntry(path, bundlePuzzlePath(0)); err != nil {
+		t.Fatalf("could not tamper with archive: %v", err)
+	}
+
+	_, err := ReadBundle(path)
+	if _, ok := err.(*ChecksumMismatchError); !ok {
+		t.Fatalf("got error %v, want *ChecksumMismatchError", err)
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright 2019 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package perspectivego
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// ReadPuzzleJSON reads a *Puzzle encoded as JSON, unlike ReadPuzzle's
+// colon-delimited text format, ReadPuzzleJSON never misinterprets field
+// values containing ":" or "," and reports malformed input as an error
+// rather than terminating the process.
+func ReadPuzzleJSON(reader io.Reader) (*Puzzle, error) {
+	puzzle := &Puzzle{}
+	if err := json.NewDecoder(reader).Decode(puzzle); err != nil {
+		return nil, err
+	}
+	return puzzle, nil
+}
+
+// WritePuzzleJSON writes puzzle to writer as JSON.
+func WritePuzzleJSON(writer io.Writer, puzzle *Puzzle) error {
+	return json.NewEncoder(writer).Encode(puzzle)
+}
+
+// ReadWorldJSON reads a *World encoded as JSON.
+func ReadWorldJSON(reader io.Reader) (*World, error) {
+	world := &World{}
+	if err := json.NewDecoder(reader).Decode(world); err != nil {
+		return nil, err
+	}
+	return world, nil
+}
+
+// WriteWorldJSON writes world to writer as JSON.
+func WriteWorldJSON(writer io.Writer, world *World) error {
+	return json.NewEncoder(writer).Encode(world)
+}
@@ -0,0 +1,82 @@
+/*
+ * Copyright 2019 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package perspectivego
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestWritePuzzleJSONRoundTripsEscapeUnsafeValues(t *testing.T) {
+	want := &Puzzle{
+		Target: 3,
+		Dialog: []*Dialog{
+			{
+				Name:    "intro",
+				Author:  "Jane, M.D.: Narrator",
+				Content: "Welcome: turn left, then right, good luck!",
+				Element: []string{"a", "b"},
+			},
+		},
+	}
+
+	var buffer bytes.Buffer
+	if err := WritePuzzleJSON(&buffer, want); err != nil {
+		t.Fatalf("WritePuzzleJSON failed: %v", err)
+	}
+
+	got, err := ReadPuzzleJSON(&buffer)
+	if err != nil {
+		t.Fatalf("ReadPuzzleJSON failed: %v", err)
+	}
+
+	if got.Dialog[0].Author != want.Dialog[0].Author {
+		t.Fatalf("got author %q, want %q", got.Dialog[0].Author, want.Dialog[0].Author)
+	}
+	if got.Dialog[0].Content != want.Dialog[0].Content {
+		t.Fatalf("got content %q, want %q", got.Dialog[0].Content, want.Dialog[0].Content)
+	}
+	if len(got.Dialog[0].Element) != 2 || got.Dialog[0].Element[0] != "a" || got.Dialog[0].Element[1] != "b" {
+		t.Fatalf("got elements %v, want [a b]", got.Dialog[0].Element)
+	}
+}
+
+// TestReadPuzzleTextFormatCorruptsColonValues documents the escaping
+// limitation of the colon-delimited text format that ReadPuzzleJSON fixes:
+// a Dialog.Content containing ":" shifts every field after it, so the
+// round trip either corrupts the Element field or fails outright instead
+// of reproducing the original Content.
+func TestReadPuzzleTextFormatCorruptsColonValues(t *testing.T) {
+	var buffer bytes.Buffer
+	if err := WritePuzzle(&buffer, &Puzzle{
+		Dialog: []*Dialog{
+			{
+				Name:     "intro",
+				Content:  "Welcome: turn left",
+				Location: &Location{X: 1, Y: 2, Z: 3},
+				Element:  []string{"a"},
+			},
+		},
+	}); err != nil {
+		t.Fatalf("WritePuzzle failed: %v", err)
+	}
+
+	puzzle, err := ReadPuzzle(&buffer)
+	if err == nil && puzzle.Dialog[0].Content == "Welcome: turn left" {
+		t.Fatal("expected the colon-delimited format to corrupt content containing \":\", but it round-tripped")
+	}
+}
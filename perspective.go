@@ -21,6 +21,7 @@ import (
 	"errors"
 	"fmt"
 	"github.com/golang/protobuf/proto"
+	"github.com/spf13/afero"
 	"io"
 	"io/ioutil"
 	"log"
@@ -29,8 +30,18 @@ import (
 	"strings"
 )
 
+// DefaultFs is the filesystem used by ReadWorldFile, WriteWorldFile,
+// ReadPuzzleFile, and WritePuzzleFile. Hosts can override it (or call the FS
+// variants directly) to load worlds and puzzles from a MemMapFs, a
+// BasePathFs rooted at a game bundle, or any other afero.Fs implementation.
+var DefaultFs afero.Fs = afero.NewOsFs()
+
 func ReadWorldFile(path string) (*World, error) {
-	file, err := os.Open(path)
+	return ReadWorldFileFS(DefaultFs, path)
+}
+
+func ReadWorldFileFS(fs afero.Fs, path string) (*World, error) {
+	file, err := fs.Open(path)
 	if err != nil {
 		return nil, err
 	}
@@ -51,7 +62,11 @@ func ReadWorldFile(path string) (*World, error) {
 }
 
 func WriteWorldFile(path string, world *World) error {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
+	return WriteWorldFileFS(DefaultFs, path, world)
+}
+
+func WriteWorldFileFS(fs afero.Fs, path string, world *World) error {
+	file, err := fs.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
 	if err != nil {
 		return err
 	}
@@ -99,7 +114,11 @@ func ReadPuzzle(reader io.Reader) (*Puzzle, error) {
 		case "description":
 			description = parts[1]
 		case "target":
-			target = StringToInt(parts[1])
+			t, err := StringToInt(parts[1])
+			if err != nil {
+				return nil, err
+			}
+			target = t
 		case "outline":
 			outline = &Outline{
 				Mesh:     parts[1],
@@ -118,57 +137,85 @@ func ReadPuzzle(reader io.Reader) (*Puzzle, error) {
 				Shader:   parts[6],
 			})
 		case "block":
+			location, err := StringToLocation(parts[4])
+			if err != nil {
+				return nil, err
+			}
 			block = append(block, &Block{
 				Name:     parts[1],
 				Mesh:     parts[2],
 				Colour:   parts[3],
-				Location: StringToLocation(parts[4]),
+				Location: location,
 				Texture:  parts[5],
 				Material: parts[6],
 				Shader:   parts[7],
 			})
 		case "goal":
+			location, err := StringToLocation(parts[4])
+			if err != nil {
+				return nil, err
+			}
 			goal = append(goal, &Goal{
 				Name:     parts[1],
 				Mesh:     parts[2],
 				Colour:   parts[3],
-				Location: StringToLocation(parts[4]),
+				Location: location,
 				Texture:  parts[5],
 				Material: parts[6],
 				Shader:   parts[7],
 			})
 		case "portal":
+			location, err := StringToLocation(parts[4])
+			if err != nil {
+				return nil, err
+			}
+			link, err := StringToLocation(parts[5])
+			if err != nil {
+				return nil, err
+			}
 			portal = append(portal, &Portal{
 				Name:     parts[1],
 				Mesh:     parts[2],
 				Colour:   parts[3],
-				Location: StringToLocation(parts[4]),
-				Link:     StringToLocation(parts[5]),
+				Location: location,
+				Link:     link,
 				Texture:  parts[6],
 				Material: parts[7],
 				Shader:   parts[8],
 			})
 		case "sphere":
+			location, err := StringToLocation(parts[4])
+			if err != nil {
+				return nil, err
+			}
 			sphere = append(sphere, &Sphere{
 				Name:     parts[1],
 				Mesh:     parts[2],
 				Colour:   parts[3],
-				Location: StringToLocation(parts[4]),
+				Location: location,
 				Texture:  parts[5],
 				Material: parts[6],
 				Shader:   parts[7],
 			})
 		case "scenery":
+			location, err := StringToLocation(parts[4])
+			if err != nil {
+				return nil, err
+			}
 			scenery = append(scenery, &Scenery{
 				Name:     parts[1],
 				Mesh:     parts[2],
 				Colour:   parts[3],
-				Location: StringToLocation(parts[4]),
+				Location: location,
 				Texture:  parts[5],
 				Material: parts[6],
 				Shader:   parts[7],
 			})
 		case "dialog":
+			location, err := StringToLocation(parts[7])
+			if err != nil {
+				return nil, err
+			}
 			dialog = append(dialog, &Dialog{
 				Name:             parts[1],
 				Type:             parts[2],
@@ -176,7 +223,7 @@ func ReadPuzzle(reader io.Reader) (*Puzzle, error) {
 				ForegroundColour: parts[4],
 				Author:           parts[5],
 				Content:          parts[6],
-				Location:         StringToLocation(parts[7]),
+				Location:         location,
 				Element:          strings.Split(parts[8], ","),
 			})
 		default:
@@ -197,46 +244,65 @@ func ReadPuzzle(reader io.Reader) (*Puzzle, error) {
 	}, nil
 }
 
-func StringToLocation(s string) *Location {
+func StringToLocation(s string) (*Location, error) {
 	parts := strings.Split(s, ",")
 	w := 0
 	x := 0
 	y := 0
 	z := 0
+	var err error
 	switch len(parts) {
 	case 4:
-		w = StringToInt(parts[0])
-		x = StringToInt(parts[1])
-		y = StringToInt(parts[2])
-		z = StringToInt(parts[3])
+		if w, err = StringToInt(parts[0]); err != nil {
+			return nil, err
+		}
+		if x, err = StringToInt(parts[1]); err != nil {
+			return nil, err
+		}
+		if y, err = StringToInt(parts[2]); err != nil {
+			return nil, err
+		}
+		if z, err = StringToInt(parts[3]); err != nil {
+			return nil, err
+		}
 	case 3:
-		x = StringToInt(parts[0])
-		y = StringToInt(parts[1])
-		z = StringToInt(parts[2])
+		if x, err = StringToInt(parts[0]); err != nil {
+			return nil, err
+		}
+		if y, err = StringToInt(parts[1]); err != nil {
+			return nil, err
+		}
+		if z, err = StringToInt(parts[2]); err != nil {
+			return nil, err
+		}
 	case 2:
-		x = StringToInt(parts[0])
-		y = StringToInt(parts[1])
+		if x, err = StringToInt(parts[0]); err != nil {
+			return nil, err
+		}
+		if y, err = StringToInt(parts[1]); err != nil {
+			return nil, err
+		}
 	case 1:
-		x = StringToInt(parts[0])
-	case 0:
-		fallthrough
+		if x, err = StringToInt(parts[0]); err != nil {
+			return nil, err
+		}
 	default:
-		log.Fatal("Could not parse location", s)
+		return nil, fmt.Errorf("could not parse location: %q", s)
 	}
 	return &Location{
 		W: int32(w),
 		X: int32(x),
 		Y: int32(y),
 		Z: int32(z),
-	}
+	}, nil
 }
 
-func StringToInt(s string) int {
+func StringToInt(s string) (int, error) {
 	index, err := strconv.Atoi(s)
 	if err != nil {
-		log.Fatal(err)
+		return 0, err
 	}
-	return index
+	return index, nil
 }
 
 func LocationToString(l *Location) string {
@@ -246,8 +312,25 @@ func LocationToString(l *Location) string {
 	return strconv.Itoa(int(l.W)) + "," + strconv.Itoa(int(l.X)) + "," + strconv.Itoa(int(l.Y)) + "," + strconv.Itoa(int(l.Z))
 }
 
+func ReadPuzzleFile(path string) (*Puzzle, error) {
+	return ReadPuzzleFileFS(DefaultFs, path)
+}
+
+func ReadPuzzleFileFS(fs afero.Fs, path string) (*Puzzle, error) {
+	file, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ReadPuzzle(file)
+}
+
 func WritePuzzleFile(path string, puzzle *Puzzle) error {
-	file, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
+	return WritePuzzleFileFS(DefaultFs, path, puzzle)
+}
+
+func WritePuzzleFileFS(fs afero.Fs, path string, puzzle *Puzzle) error {
+	file, err := fs.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, os.ModePerm)
 	if err != nil {
 		return err
 	}
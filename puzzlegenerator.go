@@ -0,0 +1,216 @@
+/*
+ * Copyright 2019 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package perspectivego
+
+import (
+	"hash/fnv"
+	"math/rand"
+)
+
+// RandomLocation is the sentinel Location used in a template Puzzle to mark
+// an entity whose position should be randomized within bounds rather than
+// placed at a fixed coordinate.
+var RandomLocation = &Location{X: -1, Y: -1, Z: -1}
+
+// RandomTarget is the sentinel Target used in a template Puzzle to mark a
+// target that should be chosen between MinTarget and MaxTarget rather than
+// fixed to a specific value.
+const RandomTarget = 0
+
+// MaxPlacementAttempts bounds the collision-avoidance retry loop so
+// generation always terminates.
+const MaxPlacementAttempts = 100
+
+// Bounds describes the inclusive coordinate range and target range a
+// PuzzleGenerator draws randomized values from.
+type Bounds struct {
+	MinX, MaxX int32
+	MinY, MaxY int32
+	MinZ, MaxZ int32
+	MinTarget  int
+	MaxTarget  int
+}
+
+// DefaultBounds is used by GeneratePuzzle when the caller has no more
+// specific Bounds for a template.
+var DefaultBounds = Bounds{
+	MinX: -10, MaxX: 10,
+	MinY: -10, MaxY: 10,
+	MinZ: -10, MaxZ: 10,
+	MinTarget: 1,
+	MaxTarget: 10,
+}
+
+// PuzzleGenerator produces deterministic puzzle variants from a template by
+// resolving sentinel locations and targets against a seeded PRNG.
+type PuzzleGenerator struct {
+	Bounds Bounds
+}
+
+// NewPuzzleGenerator returns a PuzzleGenerator using the given Bounds. Any
+// inverted pair (Max less than Min) is swapped so Generate never panics.
+func NewPuzzleGenerator(bounds Bounds) *PuzzleGenerator {
+	return &PuzzleGenerator{
+		Bounds: normalizeBounds(bounds),
+	}
+}
+
+func normalizeBounds(b Bounds) Bounds {
+	if b.MaxX < b.MinX {
+		b.MinX, b.MaxX = b.MaxX, b.MinX
+	}
+	if b.MaxY < b.MinY {
+		b.MinY, b.MaxY = b.MaxY, b.MinY
+	}
+	if b.MaxZ < b.MinZ {
+		b.MinZ, b.MaxZ = b.MaxZ, b.MinZ
+	}
+	if b.MaxTarget < b.MinTarget {
+		b.MinTarget, b.MaxTarget = b.MaxTarget, b.MinTarget
+	}
+	return b
+}
+
+// PrngFromStrings builds a deterministic *rand.Rand seeded from the FNV-64
+// hash of the given inputs, each followed by a newline. The same inputs
+// (e.g. a player ID and a puzzle ID) always yield the same sequence.
+func PrngFromStrings(input ...string) *rand.Rand {
+	hasher := fnv.New64()
+	for _, s := range input {
+		hasher.Write([]byte(s))
+		hasher.Write([]byte("\n"))
+	}
+	return rand.New(rand.NewSource(int64(hasher.Sum64())))
+}
+
+// GeneratePuzzle returns a concrete *Puzzle derived from template, replacing
+// RandomLocation and RandomTarget sentinels with values drawn from rng
+// within DefaultBounds. The result is reproducible for a given rng sequence.
+func GeneratePuzzle(template *Puzzle, rng *rand.Rand) *Puzzle {
+	return NewPuzzleGenerator(DefaultBounds).Generate(template, rng)
+}
+
+// Generate returns a concrete *Puzzle derived from template, replacing
+// RandomLocation and RandomTarget sentinels with values drawn from rng
+// within g.Bounds. The result is reproducible for a given rng sequence.
+func (g *PuzzleGenerator) Generate(template *Puzzle, rng *rand.Rand) *Puzzle {
+	g.Bounds = normalizeBounds(g.Bounds)
+
+	puzzle := &Puzzle{
+		Outline:     template.Outline,
+		Sky:         template.Sky,
+		Description: template.Description,
+		Scenery:     template.Scenery,
+		Dialog:      template.Dialog,
+	}
+
+	occupied := make(map[string]bool)
+	markOccupied := func(l *Location) {
+		if l != nil {
+			occupied[LocationToString(l)] = true
+		}
+	}
+	for _, b := range template.Block {
+		if !isRandomLocation(b.Location) {
+			markOccupied(b.Location)
+		}
+	}
+	for _, go_ := range template.Goal {
+		if !isRandomLocation(go_.Location) {
+			markOccupied(go_.Location)
+		}
+	}
+	for _, s := range template.Sphere {
+		if !isRandomLocation(s.Location) {
+			markOccupied(s.Location)
+		}
+	}
+	for _, p := range template.Portal {
+		if !isRandomLocation(p.Location) {
+			markOccupied(p.Location)
+		}
+		if !isRandomLocation(p.Link) {
+			markOccupied(p.Link)
+		}
+	}
+	for _, s := range template.Scenery {
+		markOccupied(s.Location)
+	}
+
+	for _, b := range template.Block {
+		block := *b
+		if isRandomLocation(b.Location) {
+			block.Location = g.placeLocation(rng, occupied)
+		}
+		puzzle.Block = append(puzzle.Block, &block)
+	}
+	for _, go_ := range template.Goal {
+		goal := *go_
+		if isRandomLocation(go_.Location) {
+			goal.Location = g.placeLocation(rng, occupied)
+		}
+		puzzle.Goal = append(puzzle.Goal, &goal)
+	}
+	for _, s := range template.Sphere {
+		sphere := *s
+		if isRandomLocation(s.Location) {
+			sphere.Location = g.placeLocation(rng, occupied)
+		}
+		puzzle.Sphere = append(puzzle.Sphere, &sphere)
+	}
+	for _, p := range template.Portal {
+		portal := *p
+		if isRandomLocation(p.Location) {
+			portal.Location = g.placeLocation(rng, occupied)
+		}
+		if isRandomLocation(p.Link) {
+			portal.Link = g.placeLocation(rng, occupied)
+		}
+		puzzle.Portal = append(puzzle.Portal, &portal)
+	}
+
+	if template.Target == RandomTarget {
+		puzzle.Target = uint32(g.Bounds.MinTarget + rng.Intn(g.Bounds.MaxTarget-g.Bounds.MinTarget+1))
+	} else {
+		puzzle.Target = template.Target
+	}
+
+	return puzzle
+}
+
+// placeLocation draws random coordinates within g.Bounds, retrying up to
+// MaxPlacementAttempts times to avoid colliding with an already-occupied
+// Location. occupied is updated with the chosen Location.
+func (g *PuzzleGenerator) placeLocation(rng *rand.Rand, occupied map[string]bool) *Location {
+	location := &Location{}
+	for attempt := 0; attempt < MaxPlacementAttempts; attempt++ {
+		location = &Location{
+			X: g.Bounds.MinX + rng.Int31n(g.Bounds.MaxX-g.Bounds.MinX+1),
+			Y: g.Bounds.MinY + rng.Int31n(g.Bounds.MaxY-g.Bounds.MinY+1),
+			Z: g.Bounds.MinZ + rng.Int31n(g.Bounds.MaxZ-g.Bounds.MinZ+1),
+		}
+		if !occupied[LocationToString(location)] {
+			break
+		}
+	}
+	occupied[LocationToString(location)] = true
+	return location
+}
+
+func isRandomLocation(l *Location) bool {
+	return l != nil && l.X == RandomLocation.X && l.Y == RandomLocation.Y && l.Z == RandomLocation.Z
+}
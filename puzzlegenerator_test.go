@@ -0,0 +1,115 @@
+/*
+ * Copyright 2019 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package perspectivego
+
+import (
+	"strconv"
+	"testing"
+)
+
+func TestPrngFromStringsIsDeterministic(t *testing.T) {
+	a := PrngFromStrings("player-1", "puzzle-7")
+	b := PrngFromStrings("player-1", "puzzle-7")
+	for i := 0; i < 10; i++ {
+		x := a.Int63()
+		y := b.Int63()
+		if x != y {
+			t.Fatalf("draw %d: got %d and %d from the same seed", i, x, y)
+		}
+	}
+}
+
+func TestPrngFromStringsDiffersByInput(t *testing.T) {
+	a := PrngFromStrings("player-1", "puzzle-7")
+	b := PrngFromStrings("player-2", "puzzle-7")
+	if a.Int63() == b.Int63() {
+		t.Fatal("different inputs produced the same first draw")
+	}
+}
+
+func randomTemplate() *Puzzle {
+	return &Puzzle{
+		Target: RandomTarget,
+		Block: []*Block{
+			{Name: "a", Location: RandomLocation},
+			{Name: "b", Location: RandomLocation},
+		},
+		Goal: []*Goal{
+			{Name: "g", Location: RandomLocation},
+		},
+		Sphere: []*Sphere{
+			{Name: "s", Location: RandomLocation},
+		},
+		Portal: []*Portal{
+			{Name: "p", Location: &Location{X: 3, Y: 3, Z: 3}},
+		},
+	}
+}
+
+func TestGeneratePuzzleIsReproducible(t *testing.T) {
+	template := randomTemplate()
+
+	first := GeneratePuzzle(template, PrngFromStrings("player-1", "puzzle-7"))
+	second := GeneratePuzzle(template, PrngFromStrings("player-1", "puzzle-7"))
+
+	for i := range first.Block {
+		if LocationToString(first.Block[i].Location) != LocationToString(second.Block[i].Location) {
+			t.Fatalf("block %d: got different locations across identical seeds", i)
+		}
+	}
+	if first.Target != second.Target {
+		t.Fatalf("got different targets across identical seeds: %d vs %d", first.Target, second.Target)
+	}
+}
+
+func TestGeneratePuzzleAvoidsCollisions(t *testing.T) {
+	template := randomTemplate()
+	puzzle := GeneratePuzzle(template, PrngFromStrings("collision-check"))
+
+	seen := make(map[string]string)
+	check := func(who string, l *Location) {
+		key := LocationToString(l)
+		if other, ok := seen[key]; ok {
+			t.Fatalf("%s and %s collide at %s", who, other, key)
+		}
+		seen[key] = who
+	}
+	for i, b := range puzzle.Block {
+		check("block"+strconv.Itoa(i), b.Location)
+	}
+	for i, g := range puzzle.Goal {
+		check("goal"+strconv.Itoa(i), g.Location)
+	}
+	for i, s := range puzzle.Sphere {
+		check("sphere"+strconv.Itoa(i), s.Location)
+	}
+	for i, p := range puzzle.Portal {
+		check("portal"+strconv.Itoa(i), p.Location)
+	}
+}
+
+func TestNewPuzzleGeneratorNormalizesInvertedBounds(t *testing.T) {
+	g := NewPuzzleGenerator(Bounds{
+		MinX: 10, MaxX: -10,
+		MinY: 10, MaxY: -10,
+		MinZ: 10, MaxZ: -10,
+		MinTarget: 10, MaxTarget: 1,
+	})
+
+	// Would panic on the inverted range if the bounds were left unnormalized.
+	g.Generate(randomTemplate(), PrngFromStrings("inverted-bounds"))
+}
@@ -0,0 +1,314 @@
+/*
+ * Copyright 2019 Aletheia Ware LLC
+ *
+ * Licensed under the Apache License, Version 2.0 (the "License");
+ * you may not use this file except in compliance with the License.
+ * You may obtain a copy of the License at
+ *
+ * http://www.apache.org/licenses/LICENSE-2.0
+ *
+ * Unless required by applicable law or agreed to in writing, software
+ * distributed under the License is distributed on an "AS IS" BASIS,
+ * WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+ * See the License for the specific language governing permissions and
+ * limitations under the License.
+ */
+
+package perspectivego
+
+import (
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/afero"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// WatchDebounce is the delay a Watcher waits after a filesystem event
+// before re-parsing, so editors that write-then-rename a file don't
+// trigger duplicate reloads.
+const WatchDebounce = 100 * time.Millisecond
+
+// EventKind identifies the kind of change a WatchEvent reports.
+type EventKind int
+
+const (
+	EventCreate EventKind = iota
+	EventUpdate
+	EventRemove
+)
+
+// WatchEvent reports a puzzle or world file that was parsed on startup or
+// re-parsed after a filesystem change. Err is set instead of Puzzle/World
+// when parsing fails, so a malformed edit never crashes the watching host.
+type WatchEvent struct {
+	Path   string
+	Kind   EventKind
+	Puzzle *Puzzle
+	World  *World
+	Err    error
+}
+
+// Watcher parses *.puzzle and *.world files under a root directory on
+// startup, then re-parses them as they change on disk, emitting a
+// WatchEvent per file on its channel.
+type Watcher struct {
+	root    string
+	events  chan WatchEvent
+	watcher *fsnotify.Watcher
+	done    chan struct{}
+
+	mu     sync.Mutex
+	timers map[string]*time.Timer
+}
+
+// NewWatcher begins watching root and every directory beneath it for
+// changes, then parses every *.puzzle and *.world file under root in the
+// background, emitting an EventCreate WatchEvent for each. Call Events to
+// receive WatchEvents and Close to stop watching.
+func NewWatcher(root string) (*Watcher, error) {
+	fsWatcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+
+	dirs, err := subdirectories(root)
+	if err != nil {
+		fsWatcher.Close()
+		return nil, err
+	}
+	for _, dir := range dirs {
+		if err := fsWatcher.Add(dir); err != nil {
+			fsWatcher.Close()
+			return nil, err
+		}
+	}
+
+	w := &Watcher{
+		root:    root,
+		events:  make(chan WatchEvent),
+		watcher: fsWatcher,
+		done:    make(chan struct{}),
+		timers:  make(map[string]*time.Timer),
+	}
+
+	go w.loop()
+
+	paths, err := watchablePaths(root)
+	if err != nil {
+		w.Close()
+		return nil, err
+	}
+	go func() {
+		for _, path := range paths {
+			w.emit(w.parse(path, EventCreate))
+		}
+	}()
+
+	return w, nil
+}
+
+// NewWatcherFS parses every *.puzzle and *.world file under root within fs
+// and emits one WatchEvent per file on the returned Watcher's channel,
+// which is then closed. Unlike NewWatcher, it does not watch for further
+// changes: fsnotify only supports the real OS filesystem, so an afero.Fs
+// root (e.g. an embedded or in-memory puzzle store) gets this
+// parse-once-on-open behaviour rather than live reloading.
+func NewWatcherFS(fs afero.Fs, root string) (*Watcher, error) {
+	paths, err := watchablePathsFS(fs, root)
+	if err != nil {
+		return nil, err
+	}
+
+	w := &Watcher{
+		root:   root,
+		events: make(chan WatchEvent),
+		done:   make(chan struct{}),
+	}
+
+	go func() {
+		defer close(w.events)
+		for _, path := range paths {
+			w.emit(w.parseFS(fs, path, EventCreate))
+		}
+	}()
+
+	return w, nil
+}
+
+// Events returns the channel WatchEvents are emitted on.
+func (w *Watcher) Events() <-chan WatchEvent {
+	return w.events
+}
+
+// Close stops watching, cancels any outstanding debounce timers, and
+// releases the underlying fsnotify watcher.
+func (w *Watcher) Close() error {
+	close(w.done)
+
+	w.mu.Lock()
+	for _, timer := range w.timers {
+		timer.Stop()
+	}
+	w.timers = nil
+	w.mu.Unlock()
+
+	if w.watcher != nil {
+		return w.watcher.Close()
+	}
+	return nil
+}
+
+func (w *Watcher) loop() {
+	for {
+		select {
+		case event, ok := <-w.watcher.Events:
+			if !ok {
+				return
+			}
+			if event.Op&fsnotify.Create == fsnotify.Create {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					w.watcher.Add(event.Name)
+					continue
+				}
+			}
+			if !isWatchedFile(event.Name) {
+				continue
+			}
+			kind := EventUpdate
+			switch {
+			case event.Op&fsnotify.Create == fsnotify.Create:
+				kind = EventCreate
+			case event.Op&fsnotify.Remove == fsnotify.Remove, event.Op&fsnotify.Rename == fsnotify.Rename:
+				kind = EventRemove
+			}
+			w.schedule(event.Name, kind)
+		case err, ok := <-w.watcher.Errors:
+			if !ok {
+				return
+			}
+			w.emit(WatchEvent{Err: err})
+		case <-w.done:
+			return
+		}
+	}
+}
+
+// schedule debounces reloads of path, so a burst of filesystem events (as
+// produced by editors that write then rename) results in a single parse.
+func (w *Watcher) schedule(path string, kind EventKind) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.timers == nil {
+		// Close has already run; nothing left to schedule onto.
+		return
+	}
+	if timer, ok := w.timers[path]; ok {
+		timer.Stop()
+	}
+	w.timers[path] = time.AfterFunc(WatchDebounce, func() {
+		w.mu.Lock()
+		if w.timers != nil {
+			delete(w.timers, path)
+		}
+		w.mu.Unlock()
+
+		if kind == EventRemove {
+			w.emit(WatchEvent{Path: path, Kind: EventRemove})
+			return
+		}
+		w.emit(w.parse(path, kind))
+	})
+}
+
+// emit sends event on w.events, but gives up if Close has already run so a
+// timer firing after shutdown never blocks forever on the unbuffered,
+// unread channel.
+func (w *Watcher) emit(event WatchEvent) {
+	select {
+	case w.events <- event:
+	case <-w.done:
+	}
+}
+
+func (w *Watcher) parse(path string, kind EventKind) WatchEvent {
+	switch filepath.Ext(path) {
+	case ".puzzle":
+		puzzle, err := ReadPuzzleFile(path)
+		return WatchEvent{Path: path, Kind: kind, Puzzle: puzzle, Err: err}
+	case ".world":
+		world, err := ReadWorldFile(path)
+		return WatchEvent{Path: path, Kind: kind, World: world, Err: err}
+	default:
+		return WatchEvent{Path: path, Kind: kind}
+	}
+}
+
+func (w *Watcher) parseFS(fs afero.Fs, path string, kind EventKind) WatchEvent {
+	switch filepath.Ext(path) {
+	case ".puzzle":
+		puzzle, err := ReadPuzzleFileFS(fs, path)
+		return WatchEvent{Path: path, Kind: kind, Puzzle: puzzle, Err: err}
+	case ".world":
+		world, err := ReadWorldFileFS(fs, path)
+		return WatchEvent{Path: path, Kind: kind, World: world, Err: err}
+	default:
+		return WatchEvent{Path: path, Kind: kind}
+	}
+}
+
+func isWatchedFile(path string) bool {
+	switch filepath.Ext(path) {
+	case ".puzzle", ".world":
+		return true
+	default:
+		return false
+	}
+}
+
+// subdirectories returns root and every directory beneath it, so a caller
+// can fsnotify.Add each one; fsnotify only watches a directory's immediate
+// contents, not its subtree.
+func subdirectories(root string) ([]string, error) {
+	var dirs []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		}
+		return nil
+	})
+	return dirs, err
+}
+
+func watchablePaths(root string) ([]string, error) {
+	var paths []string
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isWatchedFile(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}
+
+func watchablePathsFS(fs afero.Fs, root string) ([]string, error) {
+	var paths []string
+	err := afero.Walk(fs, root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && isWatchedFile(path) {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	return paths, err
+}